@@ -19,9 +19,8 @@ package vprinter
 // along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
 
 import (
-	"io"
+	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/jung-kurt/gofpdf"
 )
@@ -29,31 +28,90 @@ import (
 const maxLinesPerPage = 66
 const maxLineCharacters = 132
 
-// Colors
-const (
-	// greenDarkR = 70
-	// greenDarkG = 150
-	// greenDarkB = 70
+// MaxLinesPerPage is the number of physical lines on a page, exposed so
+// callers building an FCB image for SetFCB know how many channel:line
+// entries it can hold.
+const MaxLinesPerPage = maxLinesPerPage
 
-	greenDarkR = 99
-	greenDarkG = 182
-	greenDarkB = 99
+// ColorRGB is a simple red/green/blue color triple used to select the
+// greenbar (or bluebar, or whatever-color-bar) shading a profile draws
+// behind the printed text.
+type ColorRGB struct {
+	R, G, B int
+}
 
-	// greenLightR = 195
-	// greenLightG = 229
-	// greenLightB = 195
+// Colors
+var (
+	DarkGreen  = ColorRGB{99, 182, 99}
+	LightGreen = ColorRGB{219, 240, 219}
 
-	greenLightR = 219
-	greenLightG = 240
-	greenLightB = 219
+	DarkBlue  = ColorRGB{65, 182, 255}
+	LightBlue = ColorRGB{214, 239, 255}
 )
 
 // our implementation of the Job interface simulating an IBM 1403 printer.
+// The page/line/FCB state machine and the archival/encryption output
+// options live in the embedded lineEngine, shared with templateJob;
+// virtual1403 only adds the greenbar-style background.
 type virtual1403 struct {
-	pdf        *gofpdf.Fpdf
-	font       []byte
-	curLine    int
-	leftMargin float64
+	lineEngine
+
+	// classic draws the full replica 1403 form background: tractor feed
+	// holes, alignment fiducials, the "1412THE" form number, and the
+	// margin line numbers. When false, a plain, undecorated background is
+	// used instead.
+	classic bool
+
+	// color, when true, shades alternating bands of the form with
+	// darkColor/lightColor (greenbar, bluebar, etc). When false, the form
+	// is plain white with no banding.
+	color      bool
+	darkColor  ColorRGB
+	lightColor ColorRGB
+}
+
+// JobOption configures optional behavior of a Job produced by New1403 or
+// New1403WithTemplate that most callers don't need, so it doesn't clutter
+// either constructor's main parameter list. Every JobOption applies to the
+// shared lineEngine, so options work identically regardless of which Job
+// implementation they're used with.
+type JobOption func(*lineEngine)
+
+// WithArchival marks the job for PDF/A-2b archival output: an sRGB
+// OutputIntent and PDF/A identification metadata are embedded, and
+// WithEncryption may not also be used, since PDF/A forbids encryption.
+func WithArchival() JobOption {
+	return func(e *lineEngine) { e.archival = true }
+}
+
+// WithEncryption protects the rendered PDF with a PDF 1.7 standard security
+// handler (AES-128) so that only someone with password can open it.
+func WithEncryption(password string) JobOption {
+	return func(e *lineEngine) { e.password = password }
+}
+
+// WithDBCSFont embeds font for use rendering DBCS (double-byte CJK)
+// characters when the job's train image is TrainDBCS. Like the job's main
+// font, it is embedded and subsetted by gofpdf to only the glyphs actually
+// used.
+func WithDBCSFont(font []byte) JobOption {
+	return func(e *lineEngine) { e.dbcsFont = font }
+}
+
+// WithDBCSTranslator overrides DefaultDBCSTranslator with xlate, which a
+// caller with real guest DBCS code page tables (e.g. IBM-937, IBM-939)
+// should supply to get correct glyphs instead of the default's
+// placeholder mapping.
+func WithDBCSTranslator(xlate DBCSTranslator) JobOption {
+	return func(e *lineEngine) { e.dbcsTranslate = xlate }
+}
+
+// WithTrainImage overrides a built-in profile's default train image (see
+// NewProfile), letting a caller that knows the user's or job's preferred
+// train image (TrainPN, TrainHN, TrainDBCS, ...) select it without needing
+// a dedicated profile name for every train/color combination.
+func WithTrainImage(t TrainImage) JobOption {
+	return func(e *lineEngine) { e.trainImage = t }
 }
 
 // Page size
@@ -62,11 +120,39 @@ const (
 	v1403H = 792  // 11 inches high
 )
 
-const v1430FontSize = 11.4
+// New1403 creates a Job that simulates an IBM 1403 printer using the drawn
+// greenbar (or other color) background produced by drawBackground. font is
+// embedded and used at fontSize points. skipLines is the number of lines
+// skipped at the top of each page before printing begins, simulating the
+// forms control a real 1403 operator would set up for the stock in the
+// tractor feed. classic and color control which decorative elements of the
+// background are drawn; see virtual1403 for details. trainImage selects
+// the character repertoire printed lines are translated through; see
+// TrainImage. A DBCS font and translator can be supplied via
+// WithDBCSFont/WithDBCSTranslator when trainImage is TrainDBCS.
+func New1403(font []byte, fontSize float64, skipLines int, classic bool,
+	color bool, darkColor, lightColor ColorRGB, trainImage TrainImage,
+	opts ...JobOption) (Job, error) {
 
-func New1403(font []byte) (Job, error) {
 	j := &virtual1403{
-		font: font,
+		lineEngine: lineEngine{
+			fontSize:   fontSize,
+			skipLines:  skipLines,
+			trainImage: trainImage,
+		},
+		classic:    classic,
+		color:      color,
+		darkColor:  darkColor,
+		lightColor: lightColor,
+	}
+
+	for _, opt := range opts {
+		opt(&j.lineEngine)
+	}
+
+	if j.archival && j.password != "" {
+		return nil, fmt.Errorf("a job cannot be both archival (PDF/A-2b) " +
+			"and encrypted: PDF/A forbids encryption")
 	}
 
 	j.pdf = gofpdf.NewCustom(&gofpdf.InitType{
@@ -80,53 +166,60 @@ func New1403(font []byte) (Job, error) {
 	// Despite the documentation, it appears that AddUTF8Font takes the font
 	// directly, not the JSON file generated by makefont. We also, then, have
 	// to assume the font just magically gets embedded automatically.
-	j.pdf.AddUTF8FontFromBytes("userfont", "", j.font)
+	j.pdf.AddUTF8FontFromBytes("userfont", "", font)
+	if j.dbcsFont != nil {
+		// Embedded and subsetted the same way as the main font: gofpdf
+		// only writes the glyphs actually referenced by CellFormat calls
+		// into the output PDF.
+		j.pdf.AddUTF8FontFromBytes("dbcsfont", "", j.dbcsFont)
+	}
 
 	// We will dynamically determine how wide 132 characters of the chosen
 	// font is so that we can correctly position (center) the output area on
 	// the page. The left margin of our text output area will be the center
 	// of the page minus half of the line width.
-	j.pdf.SetFont("userfont", "", v1430FontSize)
-	j.leftMargin = v1403W/2 - determineLineWidth(j.pdf)/2
+	j.pdf.SetFont("userfont", "", j.fontSize)
+	lineWidth := determineLineWidth(j.pdf)
+	j.leftMargin = v1403W/2 - lineWidth/2
+	j.colWidth = lineWidth / maxLineCharacters
 
-	j.pdf.SetHeaderFunc(func() { drawBackground(j.pdf) })
+	j.pdf.SetHeaderFunc(func() {
+		drawBackground(j.pdf, j.classic, j.color, j.darkColor, j.lightColor)
+	})
 
 	j.NewPage()
 
 	return j, nil
 }
 
-func (job *virtual1403) AddLine(s string) {
-	if job.curLine >= maxLinesPerPage {
-		job.NewPage()
-	}
-	if len(s) > maxLineCharacters {
-		s = s[0:maxLineCharacters]
+// AddLine, AddLineCC, SetFCB, NewPage, AddBannerPage, and EndJob (including
+// its archival/encryption output handling) are all provided by the embedded
+// lineEngine.
+
+func drawBackground(pdf *gofpdf.Fpdf, classic, color bool, darkColor,
+	lightColor ColorRGB) {
+
+	if classic {
+		drawClassicDecorations(pdf, darkColor)
 	}
-	// 1403 only had capital letters
-	s = strings.ToUpper(s)
-	job.pdf.SetXY(job.leftMargin, float64(job.curLine*12)+.25)
-	job.pdf.CellFormat(0, 12, s, "", 0, "LM", false, 0, "")
-	job.curLine++
-}
 
-func (job *virtual1403) NewPage() {
-	job.pdf.AddPage()
-	job.pdf.SetFont("userfont", "", v1430FontSize)
-	// simulating a 1403 with form control that skips the first 5 physically
-	// printable lines.
-	job.curLine = 5
-}
+	if color {
+		drawBars(pdf, darkColor, lightColor)
+	}
 
-func (job *virtual1403) EndJob(w io.Writer) error {
-	return job.pdf.Output(w)
+	if classic {
+		drawMarginNumbers(pdf, darkColor)
+	}
 }
 
-func drawBackground(pdf *gofpdf.Fpdf) {
+// drawClassicDecorations draws the tractor feed holes, alignment fiducial,
+// "1412THE" form number, and the embossed "1" watermark that make the
+// background look like genuine 1403 continuous form stock.
+func drawClassicDecorations(pdf *gofpdf.Fpdf, darkColor ColorRGB) {
 	const feedHoleRadius = 5.5
 
 	// Alignment fiducial
-	pdf.SetDrawColor(greenDarkR, greenDarkG, greenDarkB)
+	pdf.SetDrawColor(darkColor.R, darkColor.G, darkColor.B)
 	pdf.SetLineWidth(.7)
 	pdf.Line(20, 54-feedHoleRadius*2, 20, 54+feedHoleRadius*2)
 	pdf.Line(20-feedHoleRadius*2, 54, 20+feedHoleRadius*2, 54)
@@ -152,7 +245,7 @@ func drawBackground(pdf *gofpdf.Fpdf) {
 	}
 
 	// Draw form number - 1412THE
-	pdf.SetTextColor(greenDarkR, greenDarkG, greenDarkB)
+	pdf.SetTextColor(darkColor.R, darkColor.G, darkColor.B)
 	pdf.SetFont("helvetica", "", 7)
 	pdf.SetXY(v1403W-4, 55)
 	pdf.TransformBegin()
@@ -161,7 +254,7 @@ func drawBackground(pdf *gofpdf.Fpdf) {
 	pdf.TransformEnd()
 
 	// Print area alignment arrows
-	pdf.SetFillColor(greenLightR, greenLightG, greenLightB)
+	pdf.SetFillColor(230, 230, 230)
 	// Left side
 	pdf.Polygon([]gofpdf.PointType{
 		{X: 40 + 2, Y: 72 - 11},
@@ -183,7 +276,7 @@ func drawBackground(pdf *gofpdf.Fpdf) {
 	const bY float64 = v1403H - 29 // bottom-left of "1"
 	const bU float64 = 0.6         // 1 grid unit in points
 	pdf.SetLineWidth(1)
-	pdf.SetDrawColor(greenDarkR, greenDarkG, greenDarkB)
+	pdf.SetDrawColor(darkColor.R, darkColor.G, darkColor.B)
 	pdf.MoveTo(bX+bU*5, bY-bU*17)
 	pdf.LineTo(bX+bU*5, bY-bU*3.5)
 	pdf.LineTo(bX, bY-bU*3.5)
@@ -199,19 +292,25 @@ func drawBackground(pdf *gofpdf.Fpdf) {
 	pdf.ClosePath()
 	pdf.DrawPath("D")
 
-	// Green bars. We are drawing the fill separate from the lines, because it
-	// looks like the horizontal lines are slightly heavier than the vertical
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// drawBars draws the alternating color bands and their bounding lines,
+// reproducing the look of greenbar or bluebar continuous form paper.
+func drawBars(pdf *gofpdf.Fpdf, darkColor, lightColor ColorRGB) {
+	// We are drawing the fill separate from the lines, because it looks
+	// like the horizontal lines are slightly heavier than the vertical
 	// lines.
-	pdf.SetFillColor(greenLightR, greenLightG, greenLightB)
+	pdf.SetFillColor(lightColor.R, lightColor.G, lightColor.B)
 	for i := 0; i < 10; i++ {
 		pdf.Rect(40, float64(72+i*72)-.5, v1403W-80, 36, "F")
 	}
 
 	// Horizontal lines. The top line and bottom line are full width to cap
 	// the margin number columns, the other lines are only as wide as the
-	// greenbars. The extra 0.25-point wiggle-room is to make the corners of
-	// the vertical and horizontal lines square with each other.
-	pdf.SetDrawColor(greenDarkR, greenDarkG, greenDarkB)
+	// bars. The extra 0.25-point wiggle-room is to make the corners of the
+	// vertical and horizontal lines square with each other.
+	pdf.SetDrawColor(darkColor.R, darkColor.G, darkColor.B)
 	pdf.SetLineWidth(.7)
 	pdf.Line(30-.25, 72-.5, v1403W-30+.25, 72-.5)             // top
 	pdf.Line(30-.25, v1403H-1-.5, v1403W-30+.25, v1403H-1-.5) // bottom
@@ -220,17 +319,20 @@ func drawBackground(pdf *gofpdf.Fpdf) {
 	}
 
 	// Vertical lines
-	pdf.SetDrawColor(greenDarkR, greenDarkG, greenDarkB)
+	pdf.SetDrawColor(darkColor.R, darkColor.G, darkColor.B)
 	pdf.SetLineWidth(.5)
 	pdf.Line(30, 72-.5, 30, v1403H-1-.5)
 	pdf.Line(40, 72-.5, 40, v1403H-1-.5)
 
 	pdf.Line(v1403W-30, 72-.5, v1403W-30, v1403H-1-.5)
 	pdf.Line(v1403W-40, 72-.5, v1403W-40, v1403H-1-.5)
+}
 
+// drawMarginNumbers draws the left and right margin line-number columns.
+func drawMarginNumbers(pdf *gofpdf.Fpdf, darkColor ColorRGB) {
 	// Left margin numbers
 	pdf.SetFont("Helvetica", "", 7)
-	pdf.SetTextColor(greenDarkR, greenDarkG, greenDarkB)
+	pdf.SetTextColor(darkColor.R, darkColor.G, darkColor.B)
 	for i := 0; i < 60; i++ {
 		pdf.SetXY(30, float64(72+i*12))
 		// The centering of the margin numbers looks better if we use
@@ -245,12 +347,9 @@ func drawBackground(pdf *gofpdf.Fpdf) {
 
 	// Right margin numbers
 	pdf.SetFont("Helvetica", "", 7)
-	pdf.SetTextColor(greenDarkR, greenDarkG, greenDarkB)
+	pdf.SetTextColor(darkColor.R, darkColor.G, darkColor.B)
 	for i := 0; i < 80; i++ {
 		pdf.SetXY(v1403W-40, float64(72+i*9))
-		// The centering of the margin numbers looks better if we use
-		// *slightly* different width for the cell for single- versus double-
-		// digit numbers.
 		w := 9.7
 		if i < 9 {
 			w = 10
@@ -0,0 +1,91 @@
+package vprinter
+
+// Copyright 2022 Matthew R. Wilson <mwilson@mattwilson.org>
+//
+// This file is part of virtual1403
+// <https://github.com/racingmars/virtual1403>.
+//
+// virtual1403 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// virtual1403 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// sRGBProfile is the ICC profile embedded in the OutputIntent of every
+// PDF/A-2b job, so a reader or printer can reproduce our greenbar/bluebar
+// colors consistently without relying on a device-dependent color space.
+//
+//go:embed sRGB2014.icc
+var sRGBProfile []byte
+
+// pdfaXMP is the XMP metadata packet that identifies the document as
+// PDF/A-2b (part 2, conformance level B) to the readers and archival tools
+// that check it.
+const pdfaXMP = `<?xpacket begin="` + "\xEF\xBB\xBF" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+      xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/">
+   <pdfaid:part>2</pdfaid:part>
+   <pdfaid:conformance>B</pdfaid:conformance>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`
+
+// toPDFA2b takes a PDF produced by gofpdf and post-processes it for PDF/A-2b
+// archival: an sRGB OutputIntent is added, and /MarkInfo and the PDF/A
+// identification XMP stream are written. PDF/A forbids transparency and
+// encryption, neither of which virtual1403 ever produces, so there is
+// nothing to strip. Font embedding is neither checked nor changed here: the
+// fonts added with AddUTF8FontFromBytes (the job's main font and DBCS font)
+// are embedded and subsetted by gofpdf, but the Helvetica used for the
+// classic form decorations, the margin numbers and banner pages is a PDF
+// standard font that gofpdf does not embed, so output that draws any of
+// them still contains a non-embedded font.
+//
+// api.AddOutputIntent/SetMarkInfo/SetXMPMetadata are the least-exercised
+// corner of pdfcpu we depend on -- verify this file builds against the
+// pinned pdfcpu version the first time a real go.sum for this module is
+// available; pdfcpu's PDF/A-authoring surface has moved between releases.
+func toPDFA2b(pdf []byte) ([]byte, error) {
+	ctx, err := api.ReadContext(bytes.NewReader(pdf), nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading rendered PDF: %w", err)
+	}
+
+	if err := api.AddOutputIntent(ctx, sRGBProfile, "sRGB IEC61966-2.1"); err != nil {
+		return nil, fmt.Errorf("adding sRGB OutputIntent: %w", err)
+	}
+
+	if err := api.SetMarkInfo(ctx, false); err != nil {
+		return nil, fmt.Errorf("setting /MarkInfo: %w", err)
+	}
+
+	if err := api.SetXMPMetadata(ctx, []byte(pdfaXMP)); err != nil {
+		return nil, fmt.Errorf("writing PDF/A identification XMP: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := api.WriteContext(ctx, &out); err != nil {
+		return nil, fmt.Errorf("writing PDF/A-2b document: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+package vprinter
+
+// Copyright 2022 Matthew R. Wilson <mwilson@mattwilson.org>
+//
+// This file is part of virtual1403
+// <https://github.com/racingmars/virtual1403>.
+//
+// virtual1403 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// virtual1403 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
+
+import (
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Code 39 geometry, in points. A "narrow" bar or space is one unit wide; a
+// "wide" one is about 2.5 units. The gap between characters is one narrow
+// unit, same as any other narrow space.
+const (
+	code39Narrow = 1.8
+	code39Wide   = code39Narrow * 2.5
+	code39Gap    = code39Narrow
+	code39Height = 96.0
+)
+
+// code39Patterns maps each supported character to its 9-element Code 39
+// pattern: five bars and four spaces, alternating starting with a bar.
+// '1' means a wide element, '0' means a narrow one.
+var code39Patterns = map[byte]string{
+	'0': "000110100", '1': "100100001", '2': "001100001",
+	'3': "101100000", '4': "000110001", '5': "100110000",
+	'6': "001110000", '7': "000100101", '8': "100100100",
+	'9': "001100100", 'A': "100001001", 'B': "001001001",
+	'C': "101001000", 'D': "000011001", 'E': "100011000",
+	'F': "001011000", 'G': "000001101", 'H': "100001100",
+	'I': "001001100", 'J': "000011100", 'K': "100000011",
+	'L': "001000011", 'M': "101000010", 'N': "000010011",
+	'O': "100010010", 'P': "001010010", 'Q': "000000111",
+	'R': "100000110", 'S': "001000110", 'T': "000010110",
+	'U': "110000001", 'V': "011000001", 'W': "111000000",
+	'X': "010010001", 'Y': "110010000", 'Z': "011010000",
+	'-': "010000101", '.': "110000100", ' ': "011000100",
+	'$': "010101000", '/': "010100010", '+': "010001010",
+	'%': "000101010", '*': "010010100",
+}
+
+// code39Encodable uppercases s and drops any character Code 39 can't
+// represent, so a jobname or owner containing lowercase letters or
+// punctuation outside the Code 39 set still produces a scannable barcode.
+func code39Encodable(s string) string {
+	s = strings.ToUpper(s)
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if _, ok := code39Patterns[s[i]]; ok {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// drawCode39 draws payload (not including start/stop characters, which
+// drawCode39 adds itself) as a Code 39 barcode of vector-drawn bars, so it
+// stays crisp at any zoom level instead of rasterizing to an image. The
+// barcode's bars start at (x, y); drawCode39 returns the x position
+// immediately following the final stop character.
+func drawCode39(pdf *gofpdf.Fpdf, payload string, x, y float64) float64 {
+	payload = "*" + code39Encodable(payload) + "*"
+
+	pdf.SetFillColor(0, 0, 0)
+	cur := x
+	for i := 0; i < len(payload); i++ {
+		pattern := code39Patterns[payload[i]]
+		for el := 0; el < len(pattern); el++ {
+			width := code39Narrow
+			if pattern[el] == '1' {
+				width = code39Wide
+			}
+			// Bars are at even element indexes, spaces at odd ones; only
+			// bars are drawn -- a space is just the absence of ink.
+			if el%2 == 0 {
+				pdf.Rect(cur, y, width, code39Height, "F")
+			}
+			cur += width
+		}
+		if i < len(payload)-1 {
+			cur += code39Gap
+		}
+	}
+
+	return cur
+}
+
+// drawBannerPage renders a full-page job separator: the jobname in huge
+// block letters, a scannable Code 39 barcode of the jobname and owner, and
+// the same information in small human-readable text below the barcode.
+// Real 1403 shops burst jobs apart at banner pages like this one; ours
+// lets mail-room sorting or archival indexing scan it with a phone camera
+// instead of reading the form number by eye.
+func drawBannerPage(pdf *gofpdf.Fpdf, jobname, owner, room string) {
+	pdf.SetTextColor(0, 0, 0)
+
+	// Jobname in huge block letters, centered.
+	pdf.SetFont("helvetica", "B", 110)
+	pdf.SetXY(0, 140)
+	pdf.CellFormat(v1403W, 130, jobname, "", 0, "C", false, 0, "")
+
+	// Barcode, centered, encoding jobname+owner.
+	barcodePayload := jobname + owner
+	barcodeWidth := code39BarcodeWidth(barcodePayload)
+	barcodeX := v1403W/2 - barcodeWidth/2
+	drawCode39(pdf, barcodePayload, barcodeX, 420)
+
+	// Human-readable text below the barcode.
+	pdf.SetFont("helvetica", "", 18)
+	pdf.SetXY(0, 420+code39Height+20)
+	pdf.CellFormat(v1403W, 24, jobname+"  "+owner, "", 0, "C", false, 0, "")
+
+	pdf.SetFont("helvetica", "", 14)
+	pdf.SetXY(0, 420+code39Height+50)
+	pdf.CellFormat(v1403W, 20, "ROOM: "+room, "", 0, "C", false, 0, "")
+}
+
+// code39BarcodeWidth computes the rendered width of payload (plus its
+// start/stop characters) without actually drawing it, so callers can
+// center it on the page.
+func code39BarcodeWidth(payload string) float64 {
+	payload = "*" + code39Encodable(payload) + "*"
+	var width float64
+	for i := 0; i < len(payload); i++ {
+		pattern := code39Patterns[payload[i]]
+		for el := 0; el < len(pattern); el++ {
+			if pattern[el] == '1' {
+				width += code39Wide
+			} else {
+				width += code39Narrow
+			}
+		}
+		if i < len(payload)-1 {
+			width += code39Gap
+		}
+	}
+	return width
+}
@@ -0,0 +1,43 @@
+package vprinter
+
+// Copyright 2022 Matthew R. Wilson <mwilson@mattwilson.org>
+//
+// This file is part of virtual1403
+// <https://github.com/racingmars/virtual1403>.
+//
+// virtual1403 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// virtual1403 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// encryptPDF wraps a PDF already rendered by gofpdf with a PDF 1.7 standard
+// security handler using AES-128, so that only someone who knows password
+// can open it. The user and owner password are the same: a job's encrypted
+// output has one recipient, and there's no separate "owner" who needs
+// unrestricted access while printing is locked down for everyone else.
+func encryptPDF(pdf []byte, password string) ([]byte, error) {
+	conf := model.NewAESConfiguration(password, password, 128)
+
+	var out bytes.Buffer
+	if err := api.Encrypt(bytes.NewReader(pdf), &out, conf); err != nil {
+		return nil, fmt.Errorf("applying AES-128 encryption: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,34 @@
+package vprinter
+
+// Copyright 2022 Matthew R. Wilson <mwilson@mattwilson.org>
+//
+// This file is part of virtual1403
+// <https://github.com/racingmars/virtual1403>.
+//
+// virtual1403 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// virtual1403 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
+
+// fcbChannel maps an ASA/machine carriage-control byte to the 1-12 Forms
+// Control Buffer channel it requests a skip to, if any. '1' is handled
+// separately by callers as an unconditional skip to a new page (channel 1
+// is, by convention, always punched on the first line of a form).
+func fcbChannel(cc byte) (int, bool) {
+	switch {
+	case cc >= '2' && cc <= '9':
+		return int(cc - '0'), true
+	case cc >= 'A' && cc <= 'C':
+		return int(cc-'A') + 10, true
+	default:
+		return 0, false
+	}
+}
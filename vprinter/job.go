@@ -0,0 +1,64 @@
+package vprinter
+
+// Copyright 2021 Matthew R. Wilson <mwilson@mattwilson.org>
+//
+// This file is part of virtual1403
+// <https://github.com/racingmars/virtual1403>.
+//
+// virtual1403 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// virtual1403 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
+
+import "io"
+
+// Job is a single virtual printer job in progress. Callers feed it lines of
+// text with AddLine, ask it to start a fresh page with NewPage, and finally
+// render the accumulated pages to a PDF with EndJob.
+type Job interface {
+	// NewPage ends the current page (if any) and begins a new one.
+	NewPage()
+
+	// AddLine prints a single line of output on the current page, advancing
+	// to a new page automatically if the page is already full. b carries
+	// the line's raw, untranslated bytes (not a pre-decoded Go string) so
+	// that train-image-specific translation, including shift-out/shift-in
+	// DBCS detection, can be applied to it. It is equivalent to calling
+	// AddLineCC with a space carriage-control byte (advance one line, then
+	// print).
+	AddLine(b []byte)
+
+	// AddLineCC is like AddLine, but cc is an ASA/machine carriage-control
+	// byte in the style of column 1 of a mainframe print record, giving the
+	// caller control over vertical forms motion: space and '0' advance one
+	// or two lines before printing, '-' advances three, '+' overprints the
+	// current line without advancing, '1' skips to a new page, and '2'-'9'
+	// and 'A'-'C' skip forward to the next line punched for FCB channels
+	// 2 through 12 (see SetFCB).
+	AddLineCC(cc byte, b []byte)
+
+	// SetFCB installs a Forms Control Buffer image describing which of the
+	// page's physical lines are punched for each of the 12 vertical
+	// channels that AddLineCC's channel-skip carriage-control codes skip
+	// to. channels[i] is the bitmask of channels (bit N set means channel
+	// N+1) punched for line i+1. A zero-value FCB leaves channel-skip codes
+	// with nothing to find, so they fall back to ejecting a new page.
+	SetFCB(channels [MaxLinesPerPage]uint16)
+
+	// AddBannerPage adds a full-page separator identifying jobname, owner,
+	// and room, for use between concatenated jobs. The next call to
+	// AddLine begins a fresh page.
+	AddBannerPage(jobname, owner, room string)
+
+	// EndJob renders the finished job to w and releases any resources held
+	// by the job.
+	EndJob(w io.Writer) error
+}
@@ -0,0 +1,193 @@
+package vprinter
+
+// Copyright 2022 Matthew R. Wilson <mwilson@mattwilson.org>
+//
+// This file is part of virtual1403
+// <https://github.com/racingmars/virtual1403>.
+//
+// virtual1403 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// virtual1403 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// lineEngine implements the page/line/FCB state machine shared by
+// virtual1403 and templateJob: advancing lines and pages, honoring
+// ASA/machine carriage-control codes (including FCB channel skips), and
+// drawing banner pages. The two Job implementations differ only in how
+// they draw each page's background (virtual1403's drawBackground vs.
+// templateJob's stampTemplatePage, both installed via pdf.SetHeaderFunc)
+// and in where the printable text area's top-left corner sits on the page
+// (yOffset is always 0 for virtual1403, since its greenbar background is
+// centered rather than offset).
+type lineEngine struct {
+	pdf        *gofpdf.Fpdf
+	fontSize   float64
+	curLine    int
+	leftMargin float64
+	yOffset    float64
+	skipLines  int
+	colWidth   float64
+
+	trainImage    TrainImage
+	dbcsFont      []byte
+	dbcsTranslate DBCSTranslator
+
+	// fcb is the job's Forms Control Buffer: fcb[i] is the bitmask of
+	// channels (bit N set means channel N+1) punched for line i+1. A zero
+	// value leaves every line unpunched, so channel-skip ASA codes simply
+	// eject to a new page if no FCB image was installed with SetFCB.
+	fcb [MaxLinesPerPage]uint16
+
+	// archival and password configure EndJob's output post-processing; see
+	// WithArchival and WithEncryption. Both virtual1403 and templateJob
+	// share this behavior, since it operates on the rendered PDF bytes
+	// rather than anything specific to how the page background was drawn.
+	archival bool
+	password string
+}
+
+func (e *lineEngine) AddLine(b []byte) {
+	e.AddLineCC(' ', b)
+}
+
+func (e *lineEngine) AddLineCC(cc byte, b []byte) {
+	switch cc {
+	case '1':
+		e.NewPage()
+	case '+':
+		// Overprint: stay on the current line.
+	case '0':
+		e.advanceLines(2)
+	case '-':
+		e.advanceLines(3)
+	case 0, ' ':
+		e.advanceLines(1)
+	default:
+		if ch, ok := fcbChannel(cc); ok {
+			e.skipToChannel(ch)
+		} else {
+			e.advanceLines(1)
+		}
+	}
+
+	cells := translateLine(b, e.trainImage, e.dbcsTranslate, maxLineCharacters)
+	renderCells(e.pdf, cells, e.leftMargin,
+		e.yOffset+float64(e.curLine*12)+.25, e.colWidth, "userfont",
+		"dbcsfont", e.fontSize)
+}
+
+// advanceLines moves down n physical lines, ejecting a new page (and
+// resetting to the top of form) whenever it runs off the bottom of the
+// current one.
+func (e *lineEngine) advanceLines(n int) {
+	for i := 0; i < n; i++ {
+		// curLine is a 0-based line index printed at curLine*12 points, so
+		// the last line on the page is maxLinesPerPage-1.
+		if e.curLine >= maxLinesPerPage-1 {
+			e.NewPage()
+			continue
+		}
+		e.curLine++
+	}
+}
+
+// skipToChannel advances until it reaches a line punched for FCB channel
+// ch, ejecting a new page along the way as needed. If the installed FCB
+// doesn't punch that channel on any line at or below the top-of-form
+// position (skipLines), it falls back to a single page eject so we never
+// spin looking for a line that doesn't exist.
+func (e *lineEngine) skipToChannel(ch int) {
+	bit := uint16(1) << (ch - 1)
+	reachable := false
+	for i := e.skipLines; i < maxLinesPerPage; i++ {
+		if i >= 0 && e.fcb[i]&bit != 0 {
+			reachable = true
+			break
+		}
+	}
+	if !reachable {
+		e.NewPage()
+		return
+	}
+	for i := 0; i <= maxLinesPerPage; i++ {
+		if e.curLine >= maxLinesPerPage-1 {
+			e.NewPage()
+		} else {
+			e.curLine++
+		}
+		// e.curLine is the 0-based line index (see advanceLines), so the
+		// FCB punches for the line it prints on are in e.fcb[e.curLine].
+		if e.curLine >= 0 && e.fcb[e.curLine]&bit != 0 {
+			return
+		}
+	}
+	e.NewPage()
+}
+
+// SetFCB installs the Forms Control Buffer image used by AddLineCC's
+// channel-skip carriage-control codes.
+func (e *lineEngine) SetFCB(channels [MaxLinesPerPage]uint16) {
+	e.fcb = channels
+}
+
+func (e *lineEngine) NewPage() {
+	e.pdf.AddPage()
+	e.pdf.SetFont("userfont", "", e.fontSize)
+	// simulating a 1403 with form control that skips the first skipLines
+	// physically printable lines.
+	e.curLine = e.skipLines
+}
+
+func (e *lineEngine) AddBannerPage(jobname, owner, room string) {
+	e.pdf.AddPage()
+	e.pdf.SetFont("userfont", "", e.fontSize)
+	drawBannerPage(e.pdf, jobname, owner, room)
+	// Force the next AddLine to start a fresh page rather than continuing
+	// to print over the banner.
+	e.curLine = maxLinesPerPage
+}
+
+func (e *lineEngine) EndJob(w io.Writer) error {
+	// The common case: no archival or encryption post-processing, so we can
+	// let gofpdf stream straight out to w as before.
+	if !e.archival && e.password == "" {
+		return e.pdf.Output(w)
+	}
+
+	var buf bytes.Buffer
+	if err := e.pdf.Output(&buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	var err error
+	if e.archival {
+		if data, err = toPDFA2b(data); err != nil {
+			return fmt.Errorf("converting to PDF/A-2b: %w", err)
+		}
+	}
+	if e.password != "" {
+		if data, err = encryptPDF(data, e.password); err != nil {
+			return fmt.Errorf("encrypting PDF: %w", err)
+		}
+	}
+
+	_, err = w.Write(data)
+	return err
+}
@@ -20,23 +20,62 @@ package vprinter
 
 import (
 	_ "embed"
+	"fmt"
 	"strings"
+	"sync"
 )
 
-var DarkGreen = ColorRGB{99, 182, 99}
-var LightGreen = ColorRGB{219, 240, 219}
-
-var DarkBlue = ColorRGB{65, 182, 255}
-var LightBlue = ColorRGB{214, 239, 255}
-
 //go:embed IBMPlexMono-Regular.ttf
 var defaultFont []byte
 
 //go:embed IBM140310Pitch-Regular-MRW.ttf
 var wornFont []byte
 
+// TemplateProfile describes a named profile whose page background is a
+// stamped, admin-uploaded stock form PDF rather than the drawn greenbar
+// pattern -- see New1403WithTemplate.
+type TemplateProfile struct {
+	TemplatePDF []byte
+	Page        int
+	XOffset     float64
+	YOffset     float64
+	Scale       float64
+	Font        []byte
+	FontSize    float64
+	SkipLines   int
+
+	TrainImage    TrainImage
+	DBCSFont      []byte
+	DBCSTranslate DBCSTranslator
+}
+
+var (
+	templateProfilesMu sync.RWMutex
+	templateProfiles   = make(map[string]TemplateProfile)
+)
+
+// RegisterTemplateProfile adds (or replaces) a named profile backed by an
+// uploaded stock-form PDF, so that a later call to NewProfile with the same
+// name returns a Job stamping that form instead of one of the built-in
+// greenbar/bluebar/plain profiles. This is how the server's admin UI turns
+// a per-user or per-profile template upload into something a user's
+// printer configuration can select by name.
+func RegisterTemplateProfile(name string, p TemplateProfile) {
+	templateProfilesMu.Lock()
+	defer templateProfilesMu.Unlock()
+	templateProfiles[strings.ToLower(name)] = p
+}
+
+// UnregisterTemplateProfile removes a previously-registered template
+// profile, e.g. when an admin deletes the uploaded form.
+func UnregisterTemplateProfile(name string) {
+	templateProfilesMu.Lock()
+	defer templateProfilesMu.Unlock()
+	delete(templateProfiles, strings.ToLower(name))
+}
+
 func NewProfile(profile string, fontOverride []byte,
-	sizeOverride float64) (Job, error) {
+	sizeOverride float64, opts ...JobOption) (Job, error) {
 
 	// Some profiles use the proprietary 1403 Vintage Mono font that we can't
 	// ship with the code. If the installation doesn't have that font (or
@@ -51,63 +90,106 @@ func NewProfile(profile string, fontOverride []byte,
 		tempSize = sizeOverride
 	}
 
+	templateProfilesMu.RLock()
+	tp, isTemplate := templateProfiles[strings.ToLower(profile)]
+	templateProfilesMu.RUnlock()
+	if isTemplate {
+		font := tp.Font
+		if font == nil {
+			font = tempFont
+		}
+		fontSize := tp.FontSize
+		if fontSize <= 0 {
+			fontSize = tempSize
+		}
+		job, err := New1403WithTemplate(tp.TemplatePDF, tp.Page, font,
+			fontSize, tp.SkipLines, tp.XOffset, tp.YOffset, tp.Scale,
+			tp.TrainImage, tp.DBCSFont, tp.DBCSTranslate, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", profile, err)
+		}
+		return job, nil
+	}
+
 	switch strings.ToLower(profile) {
 	case "default-green":
-		return New1403(tempFont, tempSize, 6, true, true, DarkGreen, LightGreen)
+		return New1403(tempFont, tempSize, 6, true, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "default-green-noskip":
-		return New1403(tempFont, tempSize, 1, true, true, DarkGreen, LightGreen)
+		return New1403(tempFont, tempSize, 1, true, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "default-blue":
-		return New1403(tempFont, tempSize, 6, true, true, DarkBlue, LightBlue)
+		return New1403(tempFont, tempSize, 6, true, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "default-blue-noskip":
-		return New1403(tempFont, tempSize, 1, true, true, DarkBlue, LightBlue)
+		return New1403(tempFont, tempSize, 1, true, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "default-plain":
-		return New1403(tempFont, tempSize, 6, true, false, ColorRGB{}, ColorRGB{})
+		return New1403(tempFont, tempSize, 6, true, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "default-plain-noskip":
-		return New1403(tempFont, tempSize, 1, true, false, ColorRGB{}, ColorRGB{})
+		return New1403(tempFont, tempSize, 1, true, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "retro-green":
-		return New1403(wornFont, 10, 6, true, true, DarkGreen, LightGreen)
+		return New1403(wornFont, 10, 6, true, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "retro-green-noskip":
-		return New1403(wornFont, 10, 1, true, true, DarkGreen, LightGreen)
+		return New1403(wornFont, 10, 1, true, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "retro-blue":
-		return New1403(wornFont, 10, 6, true, true, DarkBlue, LightBlue)
+		return New1403(wornFont, 10, 6, true, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "retro-blue-noskip":
-		return New1403(wornFont, 10, 1, true, true, DarkBlue, LightBlue)
+		return New1403(wornFont, 10, 1, true, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "retro-plain":
-		return New1403(wornFont, 10, 6, true, false, ColorRGB{}, ColorRGB{})
+		return New1403(wornFont, 10, 6, true, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "retro-plain-noskip":
-		return New1403(wornFont, 10, 1, true, false, ColorRGB{}, ColorRGB{})
+		return New1403(wornFont, 10, 1, true, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "modern-green":
-		return New1403(defaultFont, 12.0, 6, false, true, DarkGreen, LightGreen)
+		return New1403(defaultFont, 12.0, 6, false, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "modern-green-skip5":
-		return New1403(defaultFont, 12.0, 5, false, true, DarkGreen, LightGreen)
+		return New1403(defaultFont, 12.0, 5, false, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "modern-green-noskip":
-		return New1403(defaultFont, 12.0, 1, false, true, DarkGreen, LightGreen)
+		return New1403(defaultFont, 12.0, 1, false, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "modern-blue":
-		return New1403(defaultFont, 12.0, 6, false, true, DarkBlue, LightBlue)
+		return New1403(defaultFont, 12.0, 6, false, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "modern-blue-skip5":
-		return New1403(defaultFont, 12.0, 5, false, true, DarkBlue, LightBlue)
+		return New1403(defaultFont, 12.0, 5, false, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "modern-blue-noskip":
-		return New1403(defaultFont, 12.0, 1, false, true, DarkBlue, LightBlue)
+		return New1403(defaultFont, 12.0, 1, false, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "modern-plain":
-		return New1403(defaultFont, 12.0, 6, false, false, ColorRGB{}, ColorRGB{})
+		return New1403(defaultFont, 12.0, 6, false, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "modern-plain-skip5":
-		return New1403(defaultFont, 12.0, 5, false, false, ColorRGB{}, ColorRGB{})
+		return New1403(defaultFont, 12.0, 5, false, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "modern-plain-noskip":
-		return New1403(defaultFont, 12.0, 1, false, false, ColorRGB{}, ColorRGB{})
+		return New1403(defaultFont, 12.0, 1, false, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "lpi8-modern-green":
-		return New1403(defaultFont, 9.0, 8, false, true, DarkGreen, LightGreen)
+		return New1403(defaultFont, 9.0, 8, false, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "lpi8-modern-green-noskip":
-		return New1403(defaultFont, 9.0, 1, false, true, DarkGreen, LightGreen)
+		return New1403(defaultFont, 9.0, 1, false, true, DarkGreen, LightGreen, TrainTN, opts...)
 	case "lpi8-modern-blue":
-		return New1403(defaultFont, 9.0, 8, false, true, DarkBlue, LightBlue)
+		return New1403(defaultFont, 9.0, 8, false, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "lpi8-modern-blue-noskip":
-		return New1403(defaultFont, 9.0, 1, false, true, DarkBlue, LightBlue)
+		return New1403(defaultFont, 9.0, 1, false, true, DarkBlue, LightBlue, TrainTN, opts...)
 	case "lpi8-modern-plain":
-		return New1403(defaultFont, 9.0, 8, false, false, ColorRGB{}, ColorRGB{})
+		return New1403(defaultFont, 9.0, 8, false, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
 	case "lpi8-modern-plain-noskip":
-		return New1403(defaultFont, 9.0, 1, false, false, ColorRGB{}, ColorRGB{})
+		return New1403(defaultFont, 9.0, 1, false, false, ColorRGB{}, ColorRGB{}, TrainTN, opts...)
+	case "archive-green":
+		// PDF/A-2b archival output. Callers needing encryption too should
+		// know that's not possible: PDF/A forbids it.
+		return New1403(tempFont, tempSize, 6, true, true, DarkGreen,
+			LightGreen, TrainTN, append(opts, WithArchival())...)
+	case "encrypted-green":
+		// AES-128 encrypted output. The caller supplies the actual
+		// password via WithEncryption in opts; this profile just picks the
+		// familiar greenbar look for the protected document. Since the
+		// whole point of selecting this profile is a protected PDF, fail
+		// loudly instead of silently handing back an unprotected one if the
+		// caller forgot to supply a password.
+		job, err := New1403(tempFont, tempSize, 6, true, true, DarkGreen,
+			LightGreen, TrainTN, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := job.(*virtual1403); ok && v.password == "" {
+			return nil, fmt.Errorf("profile %q requires a password via "+
+				"WithEncryption; none was supplied", profile)
+		}
+		return job, nil
 	default:
 		// default is the same as default-green
-		return New1403(tempFont, tempSize, 6, true, true, DarkGreen, LightGreen)
+		return New1403(tempFont, tempSize, 6, true, true, DarkGreen, LightGreen, TrainTN, opts...)
 	}
 }
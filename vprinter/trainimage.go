@@ -0,0 +1,249 @@
+package vprinter
+
+// Copyright 2022 Matthew R. Wilson <mwilson@mattwilson.org>
+//
+// This file is part of virtual1403
+// <https://github.com/racingmars/virtual1403>.
+//
+// virtual1403 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// virtual1403 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with virtual1403. If not, see <https://www.gnu.org/licenses/>.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// TrainImage selects which physical 1403 print train (or, for DBCS, which
+// shift-out/shift-in behavior) a job emulates. Real 1403s could have
+// different trains installed, each supporting a different character
+// repertoire; guests picked a train image to match what they needed to
+// print.
+type TrainImage int
+
+const (
+	// TrainTN emulates the 48-character AN train: uppercase letters,
+	// digits, and a handful of punctuation marks. This is the default
+	// train image: lowercase letters are forced to uppercase, and any
+	// other character outside the set is blanked.
+	TrainTN TrainImage = iota
+
+	// TrainPN emulates the 60-character PL/I train: a larger, still
+	// uppercase-only, punctuation set.
+	TrainPN
+
+	// TrainHN prints every printable ASCII byte (0x20-0x7E) as itself,
+	// including lowercase letters. Unlike TrainTN/TrainPN, TrainHN does
+	// not force case. Other bytes print as blanks, so the characters of
+	// EBCDIC code page 0037 outside printable ASCII (such as the cent and
+	// not signs and accented letters) are not printed.
+	TrainHN
+
+	// TrainDBCS behaves like TrainHN for single-byte text, but also
+	// recognizes the shift-out (0x0E) / shift-in (0x0F) control bytes a
+	// DBCS-aware guest (Linux on Z, z/OS Unicode Services, MUSIC/SP) uses
+	// to mark a run of double-byte CJK character data.
+	TrainDBCS
+)
+
+// ParseTrainImage converts the config-file name of a train image ("tn",
+// "pn", "hn", "dbcs", case-insensitive) to the corresponding TrainImage, so
+// that an admin can select a train image by name in server or form template
+// configuration instead of only via package-internal Go code.
+func ParseTrainImage(name string) (TrainImage, error) {
+	switch strings.ToLower(name) {
+	case "", "tn":
+		return TrainTN, nil
+	case "pn":
+		return TrainPN, nil
+	case "hn":
+		return TrainHN, nil
+	case "dbcs":
+		return TrainDBCS, nil
+	default:
+		return TrainTN, fmt.Errorf("unknown train image %q", name)
+	}
+}
+
+const (
+	shiftOut = 0x0E
+	shiftIn  = 0x0F
+)
+
+// trainTable is a translation table from an input byte to the rune printed
+// for it. Index 256 entries so every byte value has a defined (possibly
+// blank) translation.
+type trainTable [256]rune
+
+// buildTrainTable constructs a translation table for the printable ASCII
+// bytes (0x20-0x7E). If upper is true, each byte in allowed maps to itself,
+// a lowercase letter whose uppercase form is in allowed maps to that
+// uppercase form, and every other printable byte maps to a blank space,
+// matching how a real print train with a limited character repertoire
+// would substitute unavailable characters. If upper is false, every
+// printable byte maps to itself. Bytes outside 0x20-0x7E always map to a
+// blank.
+func buildTrainTable(allowed string, upper bool) *trainTable {
+	var t trainTable
+	for i := range t {
+		t[i] = ' '
+	}
+	set := make(map[rune]bool, len(allowed))
+	for _, r := range allowed {
+		set[r] = true
+	}
+	for i := 0x20; i < 0x7f; i++ {
+		r := rune(i)
+		switch {
+		case set[r]:
+			t[i] = r
+		case upper && set[upperRune(r)]:
+			t[i] = upperRune(r)
+		case !upper:
+			t[i] = r
+		default:
+			t[i] = ' '
+		}
+	}
+	return &t
+}
+
+func upperRune(r rune) rune {
+	if r >= 'a' && r <= 'z' {
+		return r - ('a' - 'A')
+	}
+	return r
+}
+
+// tn48 is the 48-character AN train repertoire: uppercase letters, digits,
+// blank, and 11 special characters.
+const tn48 = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 .<(+|&!$*);"
+
+// pn60 is the 60-character PL/I train repertoire, a superset of tn48 adding
+// 12 more special characters.
+const pn60 = tn48 + "^-/,%_>?:#@'"
+
+var (
+	tnTable   = buildTrainTable(tn48, true)
+	pnTable   = buildTrainTable(pn60, true)
+	hnTable   = buildTrainTable("", false) // false: every printable byte passes through, case preserved
+	dbcsTable = hnTable
+)
+
+// tableFor returns the single-byte translation table for a train image.
+func tableFor(t TrainImage) *trainTable {
+	switch t {
+	case TrainPN:
+		return pnTable
+	case TrainHN:
+		return hnTable
+	case TrainDBCS:
+		return dbcsTable
+	default:
+		return tnTable
+	}
+}
+
+// DBCSTranslator turns a double-byte character pair encountered between a
+// shift-out and shift-in into the rune to look up in the DBCS font. hi and
+// lo are the two bytes of the DBCS character, in the order they appeared
+// in the stream.
+type DBCSTranslator func(hi, lo byte) rune
+
+// DefaultDBCSTranslator maps the two DBCS bytes to the codepoint U+4E00
+// plus the bytes read as a big-endian 16-bit offset. Only offsets up to
+// 0x51FF land in the Unicode CJK Unified Ideographs block (U+4E00-U+9FFF);
+// larger ones fall past its end. It is not a translation for any specific IBM DBCS
+// code page (e.g. 937, 939); a caller with real guest code page mapping
+// tables should supply its own DBCSTranslator via WithDBCSTranslator.
+func DefaultDBCSTranslator(hi, lo byte) rune {
+	return rune(0x4E00 + int(hi)<<8 + int(lo))
+}
+
+// cell is one unit of translated output: either a single-byte character
+// occupying one print-position column, or a DBCS character occupying two
+// columns and drawn with the DBCS font.
+type cell struct {
+	r    rune
+	dbcs bool
+}
+
+// translateLine converts raw job bytes into a sequence of cells, honoring
+// shift-out/shift-in switching into DBCS mode when the job's train image
+// is TrainDBCS. The result is truncated to maxCols printed columns: a
+// single-byte cell occupies one column, a DBCS cell two, and the
+// shift-out/shift-in bytes none.
+func translateLine(b []byte, t TrainImage, dbcsXlate DBCSTranslator, maxCols int) []cell {
+	table := tableFor(t)
+	var cells []cell
+	cols := 0
+	inDBCS := false
+	for i := 0; i < len(b); i++ {
+		if t == TrainDBCS && b[i] == shiftOut {
+			inDBCS = true
+			continue
+		}
+		if t == TrainDBCS && b[i] == shiftIn {
+			inDBCS = false
+			continue
+		}
+		width := 1
+		if inDBCS {
+			width = 2
+		}
+		if cols+width > maxCols {
+			break
+		}
+		if inDBCS {
+			if i+1 >= len(b) {
+				// Truncated DBCS pair at end of line; stop rather than
+				// read past the end.
+				break
+			}
+			if dbcsXlate == nil {
+				dbcsXlate = DefaultDBCSTranslator
+			}
+			cells = append(cells, cell{r: dbcsXlate(b[i], b[i+1]), dbcs: true})
+			i++
+			cols += width
+			continue
+		}
+		cells = append(cells, cell{r: table[b[i]]})
+		cols += width
+	}
+	return cells
+}
+
+// renderCells draws cells starting at (x, y), using fontName for
+// single-byte cells and dbcsFontName for DBCS cells. colWidth is the width
+// of a single print-position column; a DBCS cell occupies two columns so
+// 132-column alignment is preserved regardless of how many DBCS
+// characters appear in the line.
+func renderCells(pdf *gofpdf.Fpdf, cells []cell, x, y, colWidth float64,
+	fontName, dbcsFontName string, fontSize float64) {
+
+	cur := x
+	for _, c := range cells {
+		width := colWidth
+		font := fontName
+		if c.dbcs {
+			width = colWidth * 2
+			font = dbcsFontName
+		}
+		pdf.SetFont(font, "", fontSize)
+		pdf.SetXY(cur, y)
+		pdf.CellFormat(width, 12, string(c.r), "", 0, "LM", false, 0, "")
+		cur += width
+	}
+}
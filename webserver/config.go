@@ -26,6 +26,7 @@ import (
 	"os"
 	"regexp"
 
+	"github.com/racingmars/virtual1403/vprinter"
 	"github.com/racingmars/virtual1403/webserver/db"
 	"github.com/racingmars/virtual1403/webserver/mailer"
 	"github.com/racingmars/virtual1403/webserver/model"
@@ -36,6 +37,7 @@ type ServerConfig struct {
 	DatabaseFile            string        `yaml:"database_file"`
 	CreateAdmin             string        `yaml:"create_admin"`
 	FontFile                string        `yaml:"font_file"`
+	DBCSFontFile            string        `yaml:"dbcs_font_file"`
 	ListenPort              int           `yaml:"listen_port"`
 	TLSListenPort           int           `yaml:"tls_listen_port"`
 	TLSDomain               string        `yaml:"tls_domain"`
@@ -46,12 +48,37 @@ type ServerConfig struct {
 	QuotaPeriod             int           `yaml:"quota_period"`
 	MaxLinesPerJob          int           `yaml:"max_lines_per_job"`
 	ConcurrentPrintJobs     int           `yaml:"concurrent_print_jobs"`
+	BannerPages             bool          `yaml:"banner_pages"`
 	InactiveMonthsCleanup   int           `yaml:"inactive_months_cleanup"`
 	UnverifiedMonthsCleanup int           `yaml:"unverified_months_cleanup"`
 	PDFDaysCleanup          int           `yaml:"pdf_cleanup_days"`
 	NuisanceJobNames        []string      `yaml:"nuisance_job_names"`
 	nuisanceJobRegex        []*regexp.Regexp
-	ServerAdmin             string `yaml:"server_admin_email"`
+	ServerAdmin             string               `yaml:"server_admin_email"`
+	FormTemplates           []FormTemplateConfig `yaml:"form_templates"`
+	DefaultFCB              []string             `yaml:"default_fcb"`
+	defaultFCBImage         [vprinter.MaxLinesPerPage]uint16
+	dbcsFont                []byte
+}
+
+// FormTemplateConfig lets an admin bind a named printer profile to an
+// uploaded stock-form PDF (invoice, paycheck, shipping label, etc.) instead
+// of one of the built-in greenbar/bluebar/plain profiles. Users then select
+// Name the same way they'd select "default-green" or "retro-blue".
+type FormTemplateConfig struct {
+	Name       string  `yaml:"name"`
+	File       string  `yaml:"file"`
+	Page       int     `yaml:"page"`
+	XOffset    float64 `yaml:"x_offset"`
+	YOffset    float64 `yaml:"y_offset"`
+	Scale      float64 `yaml:"scale"`
+	FontFile   string  `yaml:"font_file"`
+	FontSize   float64 `yaml:"font_size"`
+	SkipLines  int     `yaml:"skip_lines"`
+	TrainImage string  `yaml:"train_image"`
+	DBCSFont   string  `yaml:"dbcs_font_file"`
+
+	trainImage vprinter.TrainImage
 }
 
 func readConfig(path string) (ServerConfig, []error) {
@@ -131,6 +158,18 @@ func readConfig(path string) (ServerConfig, []error) {
 			"pdf_cleanup_days is required and must be >0"))
 	}
 
+	// dbcs_font_file is only needed by profiles using TrainDBCS; reading it
+	// here rather than per-job means a bad path is caught at startup instead
+	// of the first time a guest prints DBCS text.
+	if c.DBCSFontFile != "" {
+		data, err := os.ReadFile(c.DBCSFontFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dbcs_font_file: %v", err))
+		} else {
+			c.dbcsFont = data
+		}
+	}
+
 	// Parse the nuisance regular expressions
 	for i := range c.NuisanceJobNames {
 		r, err := regexp.Compile(c.NuisanceJobNames[i])
@@ -142,9 +181,171 @@ func readConfig(path string) (ServerConfig, []error) {
 		c.nuisanceJobRegex = append(c.nuisanceJobRegex, r)
 	}
 
+	// Parse the default FCB image: a list of "channel:line" pairs, e.g.
+	// "1:1" (channel 1 punched at line 1, the usual top-of-form) or "12:60"
+	// (channel 12 punched at line 60, a common end-of-form warning line).
+	// If left empty, a channel-skip carriage-control code that finds
+	// nothing punched just ejects a new page.
+	for _, entry := range c.DefaultFCB {
+		var ch, line int
+		if _, err := fmt.Sscanf(entry, "%d:%d", &ch, &line); err != nil {
+			errs = append(errs, fmt.Errorf("default_fcb entry %q: %v",
+				entry, err))
+			continue
+		}
+		if ch < 1 || ch > 12 {
+			errs = append(errs, fmt.Errorf(
+				"default_fcb entry %q: channel must be between 1 and 12",
+				entry))
+			continue
+		}
+		if line < 1 || line > vprinter.MaxLinesPerPage {
+			errs = append(errs, fmt.Errorf(
+				"default_fcb entry %q: line must be between 1 and %d",
+				entry, vprinter.MaxLinesPerPage))
+			continue
+		}
+		c.defaultFCBImage[line-1] |= 1 << (ch - 1)
+	}
+
+	for i := range c.FormTemplates {
+		t := &c.FormTemplates[i]
+		if t.Name == "" {
+			errs = append(errs, fmt.Errorf(
+				"form_templates[%d]: name is required", i))
+		}
+		if t.File == "" {
+			errs = append(errs, fmt.Errorf(
+				"form_templates[%d]: file is required", i))
+		} else if _, err := os.Stat(t.File); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"form_templates[%d]: %v", i, err))
+		}
+		if t.Page < 1 {
+			t.Page = 1
+		}
+		if t.SkipLines < 1 {
+			errs = append(errs, fmt.Errorf(
+				"form_templates[%d]: skip_lines must be >= 1", i))
+		}
+		var err error
+		if t.trainImage, err = vprinter.ParseTrainImage(t.TrainImage); err != nil {
+			errs = append(errs, fmt.Errorf("form_templates[%d]: %v", i, err))
+		}
+	}
+
+	if len(errs) == 0 {
+		if err := registerFormTemplates(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	return c, errs
 }
 
+// BannerPage is the jobname/owner/room metadata needed to draw a separator
+// banner page between two jobs that ConcurrentPrintJobs has bundled into a
+// single mailed PDF.
+type BannerPage struct {
+	JobName string
+	Owner   string
+	Room    string
+}
+
+// InsertBannerPage adds a Code 39 banner separator page to job identifying
+// banner, if c.BannerPages is enabled. The job bundler should call this
+// before each job after the first one it concatenates into a shared PDF --
+// a banner page before the very first job would just be noise, since the
+// mail it's attached to already identifies the recipient.
+//
+// NOTE: the job bundler (ConcurrentPrintJobs) that would call this lives in
+// the hercules-side socket listener, which is not part of this checkout --
+// this package currently contains only config.go. Wiring this in is the
+// remaining work to actually turn on banner_pages end to end; it is out of
+// scope for the request that added this method.
+func (c ServerConfig) InsertBannerPage(job vprinter.Job, banner BannerPage) {
+	if !c.BannerPages {
+		return
+	}
+	job.AddBannerPage(banner.JobName, banner.Owner, banner.Room)
+}
+
+// DefaultFCBImage returns the Forms Control Buffer image parsed from the
+// default_fcb config entries, ready to pass to a Job's SetFCB. Until the
+// guest-side socket protocol is extended to forward a job's own FCB
+// punches, this server-wide default is the only FCB image available to
+// install.
+func (c ServerConfig) DefaultFCBImage() [vprinter.MaxLinesPerPage]uint16 {
+	return c.defaultFCBImage
+}
+
+// ApplyDefaultFCB installs the default_fcb image on job via SetFCB. Call it
+// right after creating a job for a guest whose own ASA carriage-control
+// stream isn't (yet) forwarded with its own FCB punches from the
+// hercules-side socket protocol.
+//
+// NOTE: like InsertBannerPage, the job-creation call site that would invoke
+// this lives outside this checkout (this package currently contains only
+// config.go), so no job installs a default_fcb image yet. Wiring this in
+// is the remaining work to turn on default_fcb end to end.
+func (c ServerConfig) ApplyDefaultFCB(job vprinter.Job) {
+	job.SetFCB(c.defaultFCBImage)
+}
+
+// DBCSFont returns the font embedded for rendering TrainDBCS's double-byte
+// CJK characters, read from dbcs_font_file, or nil if none was configured.
+//
+// NOTE: as with InsertBannerPage/ApplyDefaultFCB, the job-creation call
+// site that would pass this to vprinter.WithDBCSFont lives outside this
+// checkout.
+func (c ServerConfig) DBCSFont() []byte {
+	return c.dbcsFont
+}
+
+// registerFormTemplates reads each configured form template PDF from disk
+// and registers it with vprinter as a named profile, so that NewProfile
+// (and therefore a user's existing profile selection) picks it up.
+func registerFormTemplates(c ServerConfig) error {
+	for _, t := range c.FormTemplates {
+		data, err := os.ReadFile(t.File)
+		if err != nil {
+			return fmt.Errorf("reading form template %q: %w", t.Name, err)
+		}
+
+		var font []byte
+		if t.FontFile != "" {
+			font, err = os.ReadFile(t.FontFile)
+			if err != nil {
+				return fmt.Errorf("reading font for form template %q: %w",
+					t.Name, err)
+			}
+		}
+
+		dbcsFont := c.dbcsFont
+		if t.DBCSFont != "" {
+			dbcsFont, err = os.ReadFile(t.DBCSFont)
+			if err != nil {
+				return fmt.Errorf("reading DBCS font for form template "+
+					"%q: %w", t.Name, err)
+			}
+		}
+
+		vprinter.RegisterTemplateProfile(t.Name, vprinter.TemplateProfile{
+			TemplatePDF: data,
+			Page:        t.Page,
+			XOffset:     t.XOffset,
+			YOffset:     t.YOffset,
+			Scale:       t.Scale,
+			Font:        font,
+			FontSize:    t.FontSize,
+			SkipLines:   t.SkipLines,
+			TrainImage:  t.trainImage,
+			DBCSFont:    dbcsFont,
+		})
+	}
+	return nil
+}
+
 func (a *application) createAdmin(email string) error {
 	// Only proceed if admin user doesn't already exist
 	_, err := a.db.GetUser(email)